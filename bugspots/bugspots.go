@@ -1,13 +1,8 @@
 package bugspots
 
 import (
-	"errors"
-	"fmt"
 	"math"
-	"os/exec"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -24,126 +19,90 @@ const (
 	DefaultPercentile = 10.0
 )
 
-type commandOutputter func(string, ...string) (string, error)
-
-func newCommandOutputter(dir string) commandOutputter {
-	return func(name string, args ...string) (out string, err error) {
-		cmd := exec.Command(name, args...)
-		cmd.Dir = dir
-
-		outb, err := cmd.Output()
-		if err != nil {
-			return
-		}
-
-		out = strings.TrimSpace(string(outb[:]))
-		return
-	}
-}
-
-// Repo is a path to a git a repository.
+// Repo is a git repository to mine for hotspots. By default it reads
+// history with go-git, a pure-Go implementation that needs no git binary on
+// PATH and works against bare repositories; NewShellRepoByPath opts back
+// into shelling out to git for environments where that's preferable.
 type Repo struct {
-	commandOutput commandOutputter
-	Path          string
+	source historySource
+	Path   string
 }
 
-// NewRepoByPath returns a pointer to a new Repo.
+// NewRepoByPath returns a pointer to a new Repo backed by go-git.
 func NewRepoByPath(path string) *Repo {
-	return &Repo{
-		newCommandOutputter(path),
-		path,
-	}
+	return &Repo{newGogitSource(path), path}
 }
 
-func parseLsFiles(raw string) []string {
-	if raw == "" {
-		return []string{}
-	}
-	return strings.Split(raw, "\n")
+// NewShellRepoByPath returns a pointer to a new Repo that shells out to the
+// git binary on PATH instead of using go-git.
+func NewShellRepoByPath(path string) *Repo {
+	return &Repo{newShellSource(path), path}
 }
 
-// headFiles returns the files at HEAD.
-func (r *Repo) headFiles() (headFiles []string, err error) {
-	out, err := r.commandOutput("git", "ls-files")
-	if err != nil {
-		return
-	}
-	headFiles = parseLsFiles(out)
-	return
+// headFiles returns the files at rev.to (or HEAD if unset), restricted to
+// pathspec if non-empty.
+func (r *Repo) headFiles(rev revisionRange, pathspec []string) ([]string, error) {
+	return r.source.headFiles(rev, pathspec)
 }
 
-type commit struct {
-	t     time.Time
-	files []string
+// bugFixCommits returns the bug-fix commits.
+func (r *Repo) bugFixCommits(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]commit, error) {
+	return r.source.bugFixCommits(pattern, followRenames, rev, pathspec)
 }
 
-// assumes `git log --format=format:%ct --name-only'
-func parseLog(raw string) ([]commit, error) {
-	if raw == "" {
-		return []commit{}, nil
-	}
-	commits := []commit{}
-	for _, commitRaw := range strings.Split(raw, "\n\n") {
-		lines := strings.Split(commitRaw, "\n")
-		timestamp, err := strconv.Atoi(lines[0])
-		if err != nil {
-			return nil, fmt.Errorf("invalid timestamp '%v'", lines[0])
-		}
-		t := time.Unix(int64(timestamp), 0)
-		commits = append(commits, commit{t, lines[1:]})
-	}
-	return commits, nil
+// bugFixHunks returns the hunks touched by the bug-fix commits.
+func (r *Repo) bugFixHunks(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]hunkCommit, error) {
+	return r.source.bugFixHunks(pattern, followRenames, rev, pathspec)
 }
 
-// bugFixCommits returns the bug-fix commits.
-func (r *Repo) bugFixCommits(pattern string) ([]commit, error) {
-	// --diff-filter ignores commits with no files attached
-	out, err := r.commandOutput("git", "log", "--diff-filter=ACDMRTUXB",
-		"-E", "-i", "--grep", pattern, "--format=format:%ct", "--name-only")
-	if err != nil {
-		return nil, err
-	}
-	commits, err := parseLog(out)
-	if err != nil {
-		return nil, err
-	}
-	return commits, nil
+// firstCommitTime returns the timestamp of the first commit in rev.
+func (r *Repo) firstCommitTime(rev revisionRange, pathspec []string) (int64, error) {
+	return r.source.firstCommitTime(rev, pathspec)
 }
 
-func parseRevList(raw string) (int64, error) {
-	lines := strings.Split(raw, "\n")
-	if len(lines) != 2 {
-		return 0, errors.New("no commits")
-	}
-	t, err := strconv.Atoi(lines[1])
-	return int64(t), err
+// lastCommitTime returns the timestamp of the last commit in rev.
+func (r *Repo) lastCommitTime(rev revisionRange, pathspec []string) (int64, error) {
+	return r.source.lastCommitTime(rev, pathspec)
 }
 
-// firstCommitTime returns the timestamp of the first commit in the history.
-func (r *Repo) firstCommitTime() (t int64, err error) {
-	out, err := r.commandOutput("git", "rev-list", "--max-parents=0", "--format=%ct", "HEAD")
-	if err != nil {
-		return
-	}
-	return parseRevList(out)
+// readFile returns the contents of path as of rev.to (or HEAD if unset), or
+// nil if no such file exists in that tree.
+func (r *Repo) readFile(rev revisionRange, path string) ([]byte, error) {
+	return r.source.readFile(rev, path)
 }
 
-// lastCommitTime returns the timestamp of the last commit in the history.
-func (r *Repo) lastCommitTime() (t int64, err error) {
-	out, err := r.commandOutput("git", "rev-list", "--max-count=1", "--format=%ct", "HEAD")
-	if err != nil {
-		return
+// commitGraphConsumer is implemented by historySource backends that can use
+// a repository's commit-graph file (see `git commit-graph`) to accelerate
+// history traversal. The shell backend has no use for it, since the git
+// binary already consults its own commit-graph.
+type commitGraphConsumer interface {
+	setUseCommitGraph(use bool)
+}
+
+// SetUseCommitGraph controls whether the go-git backend accelerates history
+// traversal with the repository's commit-graph file, falling back to the
+// object store when one isn't present. It is a no-op on the shell backend.
+// The default is true; pass false (gobugspots's "-no-commit-graph") to rule
+// out a stale or corrupt commit-graph file as the cause of a problem.
+func (r *Repo) SetUseCommitGraph(use bool) {
+	if c, ok := r.source.(commitGraphConsumer); ok {
+		c.setUseCommitGraph(use)
 	}
-	return parseRevList(out)
 }
 
 // Bugspots is the interface to the algorithm.
 type Bugspots struct {
-	Repo       *Repo
-	pattern    string
-	minCount   int
-	maxCount   int
-	percentile float64
+	Repo          *Repo
+	pattern       string
+	minCount      int
+	maxCount      int
+	percentile    float64
+	followRenames bool
+	includeGlobs  []string
+	excludeGlobs  []string
+	ranker        Ranker
+	rev           revisionRange
+	pathspec      []string
 }
 
 // NewBugspots returns a pointer to a new Bugspots object.
@@ -154,6 +113,7 @@ func NewBugspots(repo *Repo) *Bugspots {
 		minCount:   DefaultMinCount,
 		maxCount:   DefaultMaxCount,
 		percentile: DefaultPercentile,
+		ranker:     SigmoidRanker{},
 	}
 }
 
@@ -162,6 +122,51 @@ func (b *Bugspots) SetPattern(pattern string) {
 	b.pattern = pattern
 }
 
+// SetFollowRenames makes Hotspots attribute a file's bug-fix history from
+// before it was renamed to its current-HEAD name, instead of losing it.
+func (b *Bugspots) SetFollowRenames(followRenames bool) {
+	b.followRenames = followRenames
+}
+
+// SetIncludeGlobs restricts hotspot analysis to files matching at least one
+// of the given glob patterns ("**" matches across path separators).
+func (b *Bugspots) SetIncludeGlobs(globs []string) {
+	b.includeGlobs = globs
+}
+
+// SetExcludeGlobs drops files matching at least one of the given glob
+// patterns ("**" matches across path separators) from hotspot analysis.
+func (b *Bugspots) SetExcludeGlobs(globs []string) {
+	b.excludeGlobs = globs
+}
+
+// SetRanker sets the ranking function used to weigh each bug-fix commit's
+// contribution to a file's score. The default is SigmoidRanker.
+func (b *Bugspots) SetRanker(ranker Ranker) {
+	b.ranker = ranker
+}
+
+// SetRevisionRange restricts analysis to commits reachable from to but not
+// from from, as in git's "from..to" range syntax (e.g. "v1.0..HEAD",
+// "origin/main~50..origin/main", or a bare "<sha>" for to). An empty from
+// walks back to the root commit; an empty to defaults to HEAD.
+func (b *Bugspots) SetRevisionRange(from, to string) {
+	b.rev.from = from
+	b.rev.to = to
+}
+
+// SetSince additionally drops commits older than t, as in git log's
+// "--since".
+func (b *Bugspots) SetSince(t time.Time) {
+	b.rev.since = &t
+}
+
+// SetPathspec restricts analysis to commits that touch one of the given
+// paths or their subtrees.
+func (b *Bugspots) SetPathspec(pathspec []string) {
+	b.pathspec = pathspec
+}
+
 func normalizeTimestamp(t, lo, hi int64) float64 {
 	return float64(t-lo) / float64(hi-lo)
 }
@@ -186,22 +191,37 @@ func (l hotspotList) Less(i, j int) bool { return l[i].Score > l[j].Score } // s
 
 // Hotspots returns the hotspots ranked by score.
 func (b *Bugspots) Hotspots() ([]Hotspot, error) {
-	headFiles, err := b.Repo.headFiles()
-	tfirst, err := b.Repo.firstCommitTime()
-	tlast, err := b.Repo.lastCommitTime()
-	commits, err := b.Repo.bugFixCommits(b.pattern)
+	headFiles, err := b.Repo.headFiles(b.rev, b.pathspec)
+	tfirst, err := b.Repo.firstCommitTime(b.rev, b.pathspec)
+	tlast, err := b.Repo.lastCommitTime(b.rev, b.pathspec)
+	commits, err := b.Repo.bugFixCommits(b.pattern, b.followRenames, b.rev, b.pathspec)
 	if err != nil {
 		return nil, err
 	}
+	filter, err := newFileFilter(func(path string) ([]byte, error) {
+		return b.Repo.readFile(b.rev, path)
+	}, b.includeGlobs, b.excludeGlobs)
+	if err != nil {
+		return nil, err
+	}
+	firstTime := time.Unix(tfirst, 0)
+	lastTime := time.Unix(tlast, 0)
 
 	hotspots := make(hotspotList, 0, len(headFiles))
 	for _, headFile := range headFiles {
+		if filter.excluded(headFile) {
+			continue
+		}
 		score := 0.0
 		for _, commit := range commits {
-			t := normalizeTimestamp(commit.t.Unix(), tfirst, tlast)
 			for _, file := range commit.files {
-				if file == headFile {
-					score += scoreFunc(t)
+				if file == headFile && !filter.excluded(file) {
+					score += b.ranker.Score(RankInput{
+						Time:         commit.t,
+						First:        firstTime,
+						Last:         lastTime,
+						LinesChanged: commit.churn[file],
+					})
 				}
 			}
 		}
@@ -214,6 +234,97 @@ func (b *Bugspots) Hotspots() ([]Hotspot, error) {
 	return hotspots, nil
 }
 
+// LineHotspot represents a bug-prone range of lines within a file.
+type LineHotspot struct {
+	// File is a path relative to the working directory.
+	File string
+	// StartLine and EndLine delimit the range, inclusive. They are line
+	// numbers of the file as it stood right after each contributing commit;
+	// they are not mapped forward through later commits to HEAD.
+	StartLine, EndLine int
+	// Score is the score of the range according to the ranking function.
+	Score float64
+}
+
+type lineHotspotList []LineHotspot
+
+func (l lineHotspotList) Len() int           { return len(l) }
+func (l lineHotspotList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l lineHotspotList) Less(i, j int) bool { return l[i].Score > l[j].Score } // sic
+
+// LineHotspots returns bug-prone line ranges ranked by score, by attributing
+// each bug-fix commit's score to the hunks it touched instead of to whole
+// files, then merging the hunks that overlap or touch within each file.
+// This gives actionable results for large files where the file-level score
+// in Hotspots is too coarse to point at anything in particular.
+func (b *Bugspots) LineHotspots() ([]LineHotspot, error) {
+	headFiles, err := b.Repo.headFiles(b.rev, b.pathspec)
+	tfirst, err := b.Repo.firstCommitTime(b.rev, b.pathspec)
+	tlast, err := b.Repo.lastCommitTime(b.rev, b.pathspec)
+	commits, err := b.Repo.bugFixHunks(b.pattern, b.followRenames, b.rev, b.pathspec)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newFileFilter(func(path string) ([]byte, error) {
+		return b.Repo.readFile(b.rev, path)
+	}, b.includeGlobs, b.excludeGlobs)
+	if err != nil {
+		return nil, err
+	}
+
+	headFileSet := make(map[string]bool, len(headFiles))
+	for _, f := range headFiles {
+		if !filter.excluded(f) {
+			headFileSet[f] = true
+		}
+	}
+
+	firstTime := time.Unix(tfirst, 0)
+	lastTime := time.Unix(tlast, 0)
+
+	type scoredHunk struct {
+		hunk
+		score float64
+	}
+	byFile := map[string][]scoredHunk{}
+	for _, c := range commits {
+		for _, h := range c.hunks {
+			if !headFileSet[h.file] || filter.excluded(h.file) {
+				continue
+			}
+			score := b.ranker.Score(RankInput{
+				Time:         c.t,
+				First:        firstTime,
+				Last:         lastTime,
+				LinesChanged: h.endLine - h.startLine + 1,
+			})
+			byFile[h.file] = append(byFile[h.file], scoredHunk{h, score})
+		}
+	}
+
+	lineHotspots := make(lineHotspotList, 0, len(byFile))
+	for file, hunks := range byFile {
+		sort.Slice(hunks, func(i, j int) bool { return hunks[i].startLine < hunks[j].startLine })
+
+		cur := LineHotspot{file, hunks[0].startLine, hunks[0].endLine, hunks[0].score}
+		for _, h := range hunks[1:] {
+			if h.startLine > cur.EndLine+1 {
+				lineHotspots = append(lineHotspots, cur)
+				cur = LineHotspot{file, h.startLine, h.endLine, h.score}
+				continue
+			}
+			if h.endLine > cur.EndLine {
+				cur.EndLine = h.endLine
+			}
+			cur.Score += h.score
+		}
+		lineHotspots = append(lineHotspots, cur)
+	}
+	sort.Sort(lineHotspots)
+
+	return lineHotspots, nil
+}
+
 // Slicer is a helper class that simplifies extracting a specified upper
 // percentile from a slice of Hotspot objects, given a minimum and a maximum
 // number of entries to extract.
@@ -1,14 +1,81 @@
 package bugspots
 
 import (
-	"reflect"
 	"testing"
 	"time"
 )
 
-func mockCommandOutputter(out string) commandOutputter {
-	return func(_ string, _ ...string) (string, error) {
-		return out, nil
+// fakeSource is a historySource test double that returns canned data instead
+// of reading a real repository, so that Bugspots's own logic (scoring,
+// filtering, hunk merging) can be tested independently of any backend.
+type fakeSource struct {
+	files         []string
+	commits       []commit
+	hunkCommits   []hunkCommit
+	tfirst, tlast int64
+}
+
+func (s *fakeSource) headFiles(rev revisionRange, pathspec []string) ([]string, error) {
+	return s.files, nil
+}
+
+func (s *fakeSource) bugFixCommits(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]commit, error) {
+	return s.commits, nil
+}
+
+func (s *fakeSource) bugFixHunks(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]hunkCommit, error) {
+	return s.hunkCommits, nil
+}
+
+func (s *fakeSource) firstCommitTime(rev revisionRange, pathspec []string) (int64, error) {
+	return s.tfirst, nil
+}
+
+func (s *fakeSource) lastCommitTime(rev revisionRange, pathspec []string) (int64, error) {
+	return s.tlast, nil
+}
+
+func (s *fakeSource) readFile(rev revisionRange, path string) ([]byte, error) {
+	return nil, nil
+}
+
+// TestLineHotspots covers the hunk merging/aggregation in LineHotspots: two
+// touching hunks in the same file merge into one range with a summed score,
+// a third hunk far enough away stays separate, and a hunk in another file is
+// bucketed on its own.
+func TestLineHotspots(t *testing.T) {
+	source := &fakeSource{
+		files:  []string{"a.go", "b.go"},
+		tfirst: 0,
+		tlast:  100,
+		hunkCommits: []hunkCommit{
+			{t: time.Unix(0, 0), hunks: []hunk{{file: "a.go", startLine: 1, endLine: 5}}},
+			{t: time.Unix(50, 0), hunks: []hunk{{file: "a.go", startLine: 6, endLine: 10}}},
+			{t: time.Unix(100, 0), hunks: []hunk{{file: "a.go", startLine: 20, endLine: 22}}},
+			{t: time.Unix(20, 0), hunks: []hunk{{file: "b.go", startLine: 3, endLine: 3}}},
+		},
+	}
+
+	b := NewBugspots(&Repo{source: source})
+	b.SetRanker(LinearRanker{})
+
+	got, err := b.LineHotspots()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []LineHotspot{
+		{File: "a.go", StartLine: 20, EndLine: 22, Score: 1},
+		{File: "a.go", StartLine: 1, EndLine: 10, Score: 0.5},
+		{File: "b.go", StartLine: 3, EndLine: 3, Score: 0.2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, expected %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got[%d] = %+v, expected %+v", i, got[i], want[i])
+		}
 	}
 }
 
@@ -29,61 +96,3 @@ func TestNormalizeTimestamp(t *testing.T) {
 		}
 	}
 }
-
-func TestRepoHeadFiles(t *testing.T) {
-	var tests = []struct {
-		in  string
-		out []string
-	}{
-		{"", []string{}},
-		{"foo", []string{"foo"}},
-		{"foo\nbar", []string{"foo", "bar"}},
-	}
-
-	repo := &Repo{}
-	for _, tt := range tests {
-		repo.commandOutput = mockCommandOutputter(tt.in)
-		actual, _ := repo.headFiles()
-		if !reflect.DeepEqual(actual, tt.out) {
-			t.Errorf("got %#v, expected %#v", actual, tt.out)
-		}
-	}
-}
-
-func TestRepoBugFixCommits(t *testing.T) {
-	var tests = []struct {
-		in  string
-		out []commit
-	}{
-		{"", []commit{}},
-		{"1\nfoo\nbar\n\n2\nbaz", []commit{
-			commit{t: time.Unix(1, 0), files: []string{"foo", "bar"}},
-			commit{t: time.Unix(2, 0), files: []string{"baz"}},
-		}},
-	}
-
-	repo := &Repo{}
-	for _, tt := range tests {
-		repo.commandOutput = mockCommandOutputter(tt.in)
-		actual, _ := repo.bugFixCommits(DefaultCommitPattern)
-		if !reflect.DeepEqual(actual, tt.out) {
-			t.Errorf("got %#v, expected %#v", actual, tt.out)
-		}
-	}
-}
-
-func TestFirstCommitTime(t *testing.T) {
-	repo := &Repo{commandOutput: mockCommandOutputter("hash\n1")}
-	actual, err := repo.firstCommitTime()
-	if actual != 1 {
-		t.Errorf("got (%v, %v), expected (%v, <nil>)", actual, err, 1)
-	}
-}
-
-func TestLastCommitTime(t *testing.T) {
-	repo := &Repo{commandOutput: mockCommandOutputter("hash\n2")}
-	actual, err := repo.lastCommitTime()
-	if actual != 2 {
-		t.Errorf("got (%v, %v), expected (%v, <nil>)", actual, err, 2)
-	}
-}
@@ -0,0 +1,55 @@
+package bugspots
+
+import (
+	"github.com/go-git/go-git/v5"
+	commitgraphfmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// commitGraphPath is where `git commit-graph write` stores a single,
+// unsplit commit-graph file. gobugspots doesn't follow the chained,
+// split form under objects/info/commit-graphs/ -- it simply falls back to
+// the object store for those repositories, the same as if no commit-graph
+// existed at all.
+const commitGraphPath = "objects/info/commit-graph"
+
+// nodeIndex returns a CommitNodeIndex for repo, loading and caching
+// objects/info/commit-graph the first time it's needed so that repeated
+// Hotspots calls against the same Repo reuse it. If useCommitGraph is false
+// or no commit-graph file is present, the returned index falls back to
+// reading full commit objects from the object store.
+func (s *gogitSource) nodeIndex(repo *git.Repository) commitgraph.CommitNodeIndex {
+	if s.cgIndex != nil {
+		return s.cgIndex
+	}
+
+	var idx commitgraphfmt.Index
+	if s.useCommitGraph {
+		idx = openCommitGraph(repo)
+	}
+	s.cgIndex = commitgraph.NewGraphCommitNodeIndex(idx, repo.Storer)
+	return s.cgIndex
+}
+
+// openCommitGraph returns the parsed commit-graph index for repo, or nil if
+// repo isn't filesystem-backed, has no commit-graph file, or the file can't
+// be parsed. Any of these are treated as a silent fallback rather than an
+// error, matching git's own behavior when the commit-graph is missing or
+// stale.
+func openCommitGraph(repo *git.Repository) commitgraphfmt.Index {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil
+	}
+	f, err := storage.Filesystem().Open(commitGraphPath)
+	if err != nil {
+		return nil
+	}
+	idx, err := commitgraphfmt.OpenFileIndex(f)
+	if err != nil {
+		f.Close()
+		return nil
+	}
+	return idx
+}
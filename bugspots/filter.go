@@ -0,0 +1,168 @@
+package bugspots
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// generatedAttributes are the gitattributes that mark a file as generated
+// and therefore uninteresting for hotspot analysis.
+var generatedAttributes = []string{"linguist-generated", "bugspots-ignore"}
+
+// fileFilter decides whether a path should be excluded from hotspot
+// analysis, based on .gitattributes, an optional .bugspotsignore file, and
+// user-supplied include/exclude globs.
+type fileFilter struct {
+	includeGlobs  []string
+	excludeGlobs  []string
+	attrMatcher   gitattributes.Matcher
+	ignoreMatcher gitignore.Matcher
+}
+
+// fileReader returns the contents of path as of the tree being analyzed, or
+// nil if no such file exists there.
+type fileReader func(path string) ([]byte, error)
+
+// newFileFilter builds a fileFilter for the repository read by readFile.
+// Missing .gitattributes/.bugspotsignore files are not an error -- they
+// simply leave the corresponding matcher unset. readFile reads through the
+// repository's tree rather than the filesystem, so filtering works against
+// bare repositories and other non-filesystem storers too.
+func newFileFilter(readFile fileReader, includeGlobs, excludeGlobs []string) (*fileFilter, error) {
+	f := &fileFilter{includeGlobs: includeGlobs, excludeGlobs: excludeGlobs}
+
+	attrs, err := readGitattributes(readFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) > 0 {
+		f.attrMatcher = gitattributes.NewMatcher(attrs)
+	}
+
+	patterns, err := readBugspotsignore(readFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) > 0 {
+		f.ignoreMatcher = gitignore.NewMatcher(patterns)
+	}
+
+	return f, nil
+}
+
+func readGitattributes(readFile fileReader) ([]gitattributes.MatchAttribute, error) {
+	data, err := readFile(".gitattributes")
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return gitattributes.ReadAttributes(bytes.NewReader(data), nil, true)
+}
+
+func readBugspotsignore(readFile fileReader) ([]gitignore.Pattern, error) {
+	data, err := readFile(".bugspotsignore")
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	patterns := []gitignore.Pattern{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+	return patterns, nil
+}
+
+// excluded reports whether path should be dropped from hotspot analysis.
+func (f *fileFilter) excluded(path string) bool {
+	if f == nil {
+		return false
+	}
+
+	parts := strings.Split(path, "/")
+
+	if f.attrMatcher != nil {
+		attrs, ok := f.attrMatcher.Match(parts, generatedAttributes)
+		if ok {
+			for _, name := range generatedAttributes {
+				if a, present := attrs[name]; present && a.IsSet() {
+					return true
+				}
+			}
+		}
+	}
+
+	if f.ignoreMatcher != nil && f.ignoreMatcher.Match(parts, false) {
+		return true
+	}
+
+	if len(f.includeGlobs) > 0 && !matchesAnyGlob(f.includeGlobs, path) {
+		return true
+	}
+
+	if matchesAnyGlob(f.excludeGlobs, path) {
+		return true
+	}
+
+	return false
+}
+
+func matchesAnyGlob(globs []string, path string) bool {
+	for _, g := range globs {
+		if globMatch(g, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches name against pattern, understanding "**" as matching
+// across path separators in addition to the "*"/"?" support of path.Match.
+func globMatch(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, err := path.Match(pattern, name)
+		return err == nil && ok
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 3
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			re.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			re.WriteString("[^/]")
+			i++
+		case strings.ContainsRune(`.+()|^$[]{}\`, rune(pattern[i])):
+			re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			i++
+		default:
+			re.WriteByte(pattern[i])
+			i++
+		}
+	}
+	re.WriteString("$")
+
+	matched, err := regexp.MatchString(re.String(), name)
+	return err == nil && matched
+}
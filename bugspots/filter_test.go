@@ -0,0 +1,55 @@
+package bugspots
+
+import "testing"
+
+func TestNewFileFilter(t *testing.T) {
+	files := map[string][]byte{
+		".gitattributes":  []byte("*.min.js linguist-generated\n"),
+		".bugspotsignore": []byte("vendor/**\n"),
+	}
+	readFile := func(path string) ([]byte, error) {
+		return files[path], nil
+	}
+
+	filter, err := newFileFilter(readFile, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tests = []struct {
+		path string
+		out  bool
+	}{
+		{"foo.go", false},
+		{"foo.min.js", true},
+		{"vendor/foo/bar.go", true},
+	}
+	for _, tt := range tests {
+		if actual := filter.excluded(tt.path); actual != tt.out {
+			t.Errorf("excluded(%q) = %v, expected %v", tt.path, actual, tt.out)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		name    string
+		out     bool
+	}{
+		{"*.min.js", "foo.min.js", true},
+		{"*.min.js", "vendor/foo.min.js", false},
+		{"vendor/**", "vendor/foo/bar.go", true},
+		{"vendor/**", "internal/bar.go", false},
+		{"**/*.pb.go", "api/v1/thing.pb.go", true},
+		{"**/*.pb.go", "thing.pb.go", true},
+		{"**/*.pb.go", "thing.go", false},
+	}
+
+	for _, tt := range tests {
+		actual := globMatch(tt.pattern, tt.name)
+		if actual != tt.out {
+			t.Errorf("globMatch(%q, %q) = %v, expected %v", tt.pattern, tt.name, actual, tt.out)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+package bugspots
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+)
+
+// gogitSource is a historySource backed by go-git instead of the git
+// binary. It works against any repository go-git can open -- including
+// bare repositories and repositories backed by a non-filesystem storer --
+// without forking a git process per query.
+type gogitSource struct {
+	path string
+	repo *git.Repository
+
+	// useCommitGraph and cgIndex implement the commit-graph acceleration
+	// described on nodeIndex, in commitgraph.go.
+	useCommitGraph bool
+	cgIndex        commitgraph.CommitNodeIndex
+}
+
+// newGogitSource returns a historySource that reads path with go-git.
+func newGogitSource(path string) *gogitSource {
+	return &gogitSource{path: path, useCommitGraph: true}
+}
+
+// setUseCommitGraph implements commitGraphConsumer.
+func (s *gogitSource) setUseCommitGraph(use bool) {
+	s.useCommitGraph = use
+}
+
+// open lazily opens and caches the underlying go-git repository.
+func (s *gogitSource) open() (*git.Repository, error) {
+	if s.repo != nil {
+		return s.repo, nil
+	}
+	repo, err := git.PlainOpen(s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.repo = repo
+	return repo, nil
+}
+
+func (s *gogitSource) headCommit(repo *git.Repository) (*object.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(head.Hash())
+}
+
+// resolveCommit resolves rev (a branch, tag, SHA, or similar) to a commit,
+// defaulting to HEAD if rev is empty.
+func (s *gogitSource) resolveCommit(repo *git.Repository, rev string) (*object.Commit, error) {
+	if rev == "" {
+		return s.headCommit(repo)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(*hash)
+}
+
+// resolveHash resolves rev the same way resolveCommit does, but returns
+// just the hash, without loading the full commit object.
+func (s *gogitSource) resolveHash(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	if rev == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return head.Hash(), nil
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return *hash, nil
+}
+
+// readFile returns the contents of path as of rev.to (or HEAD if unset), or
+// nil if no such file exists in that tree. It reads through the commit's
+// tree rather than the filesystem, so it works against bare repositories
+// and other non-filesystem storers too.
+func (s *gogitSource) readFile(rev revisionRange, path string) ([]byte, error) {
+	repo, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.resolveCommit(repo, rev.to)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, err
+	}
+	f, err := tree.File(path)
+	if err != nil {
+		if err == object.ErrFileNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	contents, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(contents), nil
+}
+
+// headFiles returns the files at rev.to (or HEAD if unset), restricted to
+// pathspec if non-empty.
+func (s *gogitSource) headFiles(rev revisionRange, pathspec []string) ([]string, error) {
+	repo, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.resolveCommit(repo, rev.to)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	headFiles := []string{}
+	walker := tree.Files()
+	defer walker.Close()
+	for {
+		f, err := walker.Next()
+		if err != nil {
+			break
+		}
+		if matchesPathspec(pathspec, f.Name) {
+			headFiles = append(headFiles, f.Name)
+		}
+	}
+	return headFiles, nil
+}
+
+// ancestors returns the hash set of node and all of its ancestors, walked
+// through the commit-graph index rather than the object store when
+// possible (see nodeIndex).
+func ancestors(idx commitgraph.CommitNodeIndex, node commitgraph.CommitNode) (map[plumbing.Hash]bool, error) {
+	set := map[plumbing.Hash]bool{}
+	iter := commitgraph.NewCommitNodeIterCTime(node, nil, nil)
+	defer iter.Close()
+	err := iter.ForEach(func(c commitgraph.CommitNode) error {
+		set[c.ID()] = true
+		return nil
+	})
+	return set, err
+}
+
+// commitsInRange returns the commits reachable from rev.to (or HEAD) but
+// not from rev.from, newest first, dropping any older than rev.since. The
+// traversal itself -- determining reachability, parentage, and order --
+// runs against the commit-graph index when one is available, so that only
+// the commits that actually survive the range need their full object
+// parsed from the object store.
+func (s *gogitSource) commitsInRange(repo *git.Repository, rev revisionRange) ([]*object.Commit, error) {
+	idx := s.nodeIndex(repo)
+
+	toHash, err := s.resolveHash(repo, rev.to)
+	if err != nil {
+		return nil, err
+	}
+	toNode, err := idx.Get(toHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var exclude map[plumbing.Hash]bool
+	if rev.from != "" {
+		fromHash, err := s.resolveHash(repo, rev.from)
+		if err != nil {
+			return nil, err
+		}
+		fromNode, err := idx.Get(fromHash)
+		if err != nil {
+			return nil, err
+		}
+		exclude, err = ancestors(idx, fromNode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commits := []*object.Commit{}
+	iter := commitgraph.NewCommitNodeIterCTime(toNode, exclude, nil)
+	defer iter.Close()
+	err = iter.ForEach(func(node commitgraph.CommitNode) error {
+		if rev.since != nil && node.CommitTime().Before(*rev.since) {
+			return nil
+		}
+		c, err := node.Commit()
+		if err != nil {
+			return err
+		}
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// commitFiles returns the files touched by c that fall under pathspec,
+// relative to its first parent, along with the number of lines each one
+// added and removed. A root commit has no parent to diff against, so all of
+// its files are reported with no churn, mirroring how `git log --name-only`
+// treats the initial commit.
+func commitFiles(c *object.Commit, pathspec []string) ([]string, map[string]int, error) {
+	if c.NumParents() == 0 {
+		tree, err := c.Tree()
+		if err != nil {
+			return nil, nil, err
+		}
+		files := []string{}
+		walker := tree.Files()
+		defer walker.Close()
+		for {
+			f, err := walker.Next()
+			if err != nil {
+				break
+			}
+			if matchesPathspec(pathspec, f.Name) {
+				files = append(files, f.Name)
+			}
+		}
+		return files, map[string]int{}, nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	patch, err := parent.Patch(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := []string{}
+	for _, fp := range patch.FilePatches() {
+		_, to := fp.Files()
+		if to != nil && matchesPathspec(pathspec, to.Path()) {
+			files = append(files, to.Path())
+		}
+	}
+
+	churn := map[string]int{}
+	for _, stat := range patch.Stats() {
+		if matchesPathspec(pathspec, stat.Name) {
+			churn[stat.Name] = stat.Addition + stat.Deletion
+		}
+	}
+
+	return files, churn, nil
+}
+
+// bugFixCommits returns the commits whose message matches pattern.
+func (s *gogitSource) bugFixCommits(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]commit, error) {
+	repo, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.commitsInRange(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []commit{}
+	for _, c := range candidates {
+		if !re.MatchString(c.Message) {
+			continue
+		}
+		files, churn, err := commitFiles(c, pathspec)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		commits = append(commits, commit{c.Committer.When, files, churn})
+	}
+
+	if followRenames {
+		renames, err := s.renameMap(repo)
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range commits {
+			churn := map[string]int{}
+			for j, file := range c.files {
+				canonical := canonicalizePath(renames, file)
+				commits[i].files[j] = canonical
+				churn[canonical] += c.churn[file]
+			}
+			commits[i].churn = churn
+		}
+	}
+
+	return commits, nil
+}
+
+// commitHunks returns the hunks touched by c that fall under pathspec,
+// relative to its first parent, by encoding the commit's patch as a
+// zero-context unified diff and scanning its hunk headers. A root commit
+// has no parent to diff against, so it contributes no hunks.
+func commitHunks(c *object.Commit, pathspec []string) ([]hunk, error) {
+	if c.NumParents() == 0 {
+		return nil, nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := parent.Patch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := diff.NewUnifiedEncoder(&buf, 0).Encode(patch); err != nil {
+		return nil, err
+	}
+
+	hunks := []hunk{}
+	for _, h := range scanHunks(strings.Split(buf.String(), "\n")) {
+		if matchesPathspec(pathspec, h.file) {
+			hunks = append(hunks, h)
+		}
+	}
+	return hunks, nil
+}
+
+// bugFixHunks returns the same commits as bugFixCommits, broken down into
+// the hunks they touched instead of whole files.
+func (s *gogitSource) bugFixHunks(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]hunkCommit, error) {
+	repo, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.commitsInRange(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []hunkCommit{}
+	for _, c := range candidates {
+		if !re.MatchString(c.Message) {
+			continue
+		}
+		hunks, err := commitHunks(c, pathspec)
+		if err != nil {
+			return nil, err
+		}
+		if len(hunks) == 0 {
+			continue
+		}
+		commits = append(commits, hunkCommit{c.Committer.When, hunks})
+	}
+
+	if followRenames {
+		renames, err := s.renameMap(repo)
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range commits {
+			for j, h := range c.hunks {
+				commits[i].hunks[j].file = canonicalizePath(renames, h.file)
+			}
+		}
+	}
+
+	return commits, nil
+}
+
+// renameMap returns a map of every historical path to the path it was
+// renamed to, built by diffing each commit's tree against its first
+// parent's and matching deletions against insertions of the same blob, oldest
+// commit first so a chain of renames can be followed forward to a file's
+// current-HEAD name.
+func (s *gogitSource) renameMap(repo *git.Repository) (map[string]string, error) {
+	idx := s.nodeIndex(repo)
+
+	headHash, err := s.resolveHash(repo, "")
+	if err != nil {
+		return nil, err
+	}
+	head, err := idx.Get(headHash)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []commitgraph.CommitNode{}
+	iter := commitgraph.NewCommitNodeIterCTime(head, nil, nil)
+	defer iter.Close()
+	if err := iter.ForEach(func(c commitgraph.CommitNode) error {
+		commits = append(commits, c)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	renames := map[string]string{}
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if c.NumParents() == 0 {
+			continue
+		}
+		parent, err := c.ParentNode(0)
+		if err != nil {
+			return nil, err
+		}
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return nil, err
+		}
+		changes, err := parentTree.Diff(tree)
+		if err != nil {
+			return nil, err
+		}
+
+		deleted := map[string]string{}
+		inserted := map[string]string{}
+		for _, ch := range changes {
+			switch {
+			case ch.From.Name != "" && ch.To.Name != "" && ch.From.Name != ch.To.Name:
+				// go-git reports a `git mv` (possibly with an edit folded
+				// in) as a single modify-Change with both names set,
+				// rather than as a delete-Change paired with an
+				// insert-Change.
+				renames[ch.From.Name] = ch.To.Name
+			case ch.From.Name != "" && ch.To.Name == "":
+				deleted[ch.From.TreeEntry.Hash.String()] = ch.From.Name
+			case ch.From.Name == "" && ch.To.Name != "":
+				inserted[ch.To.TreeEntry.Hash.String()] = ch.To.Name
+			}
+		}
+		for hash, oldPath := range deleted {
+			if newPath, ok := inserted[hash]; ok && newPath != oldPath {
+				renames[oldPath] = newPath
+			}
+		}
+	}
+	return renames, nil
+}
+
+// firstCommitTime returns the timestamp of the first (oldest) commit in rev.
+func (s *gogitSource) firstCommitTime(rev revisionRange, pathspec []string) (int64, error) {
+	repo, err := s.open()
+	if err != nil {
+		return 0, err
+	}
+	commits, err := s.commitsInRange(repo, rev)
+	if err != nil {
+		return 0, err
+	}
+
+	var first *time.Time
+	for _, c := range commits {
+		if len(pathspec) > 0 {
+			files, _, err := commitFiles(c, pathspec)
+			if err != nil {
+				return 0, err
+			}
+			if len(files) == 0 {
+				continue
+			}
+		}
+		if first == nil || c.Committer.When.Before(*first) {
+			t := c.Committer.When
+			first = &t
+		}
+	}
+	if first == nil {
+		return 0, errors.New("no commits")
+	}
+	return first.Unix(), nil
+}
+
+// lastCommitTime returns the timestamp of the last (newest) commit in rev.
+func (s *gogitSource) lastCommitTime(rev revisionRange, pathspec []string) (int64, error) {
+	repo, err := s.open()
+	if err != nil {
+		return 0, err
+	}
+	commits, err := s.commitsInRange(repo, rev)
+	if err != nil {
+		return 0, err
+	}
+
+	var last *time.Time
+	for _, c := range commits {
+		if len(pathspec) > 0 {
+			files, _, err := commitFiles(c, pathspec)
+			if err != nil {
+				return 0, err
+			}
+			if len(files) == 0 {
+				continue
+			}
+		}
+		if last == nil || c.Committer.When.After(*last) {
+			t := c.Committer.When
+			last = &t
+		}
+	}
+	if last == nil {
+		return 0, errors.New("no commits")
+	}
+	return last.Unix(), nil
+}
@@ -0,0 +1,255 @@
+package bugspots
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRepo creates an empty git repository in a temporary directory and
+// returns its path. It shells out to the real git binary to build fixtures,
+// so that the commits gogitSource reads are exactly what git itself would
+// produce -- including the rename detection covered by
+// TestGogitSourceRenameMap below.
+func testRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "config", "user.name", "Test")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commitAll(t *testing.T, dir, message string) {
+	t.Helper()
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", message)
+}
+
+// TestGogitSourceRenameMap covers a plain `git mv` and a `git mv` combined
+// with a content edit in the same commit. go-git's tree Diff reports both
+// as a single Modify-Change with From.Name and To.Name set to the old and
+// new path, rather than as a paired delete-Change and insert-Change -- the
+// cases renameMap originally handled.
+func TestGogitSourceRenameMap(t *testing.T) {
+	dir := testRepo(t)
+
+	writeFile(t, dir, "foo.go", "package foo\n")
+	commitAll(t, dir, "fix #1: add foo")
+
+	runGit(t, dir, "mv", "foo.go", "bar.go")
+	commitAll(t, dir, "rename foo to bar")
+
+	bazContent := "package baz\n\nfunc Baz() {\n" +
+		"\t// line one\n\t// line two\n\t// line three\n\t// line four\n" +
+		"\t// line five\n\t// line six\n\t// line seven\n\t// line eight\n" +
+		"\t// line nine\n\t// line ten\n}\n"
+	writeFile(t, dir, "baz.go", bazContent)
+	commitAll(t, dir, "fix #2: add baz")
+
+	runGit(t, dir, "mv", "baz.go", "qux.go")
+	writeFile(t, dir, "qux.go", strings.Replace(bazContent, "line one", "line ONE edited", 1))
+	commitAll(t, dir, "rename baz to qux and tweak it")
+
+	s := newGogitSource(dir)
+	repo, err := s.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renames, err := s.renameMap(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renames["foo.go"] != "bar.go" {
+		t.Errorf("renames[foo.go] = %q, expected bar.go", renames["foo.go"])
+	}
+	if renames["baz.go"] != "qux.go" {
+		t.Errorf("renames[baz.go] = %q, expected qux.go", renames["baz.go"])
+	}
+
+	commits, err := s.bugFixCommits(DefaultCommitPattern, true, revisionRange{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, c := range commits {
+		for _, f := range c.files {
+			got[f] = true
+		}
+	}
+	if !got["bar.go"] || got["foo.go"] {
+		t.Errorf("bugFixCommits with followRenames didn't canonicalize foo.go to bar.go: %v", got)
+	}
+	if !got["qux.go"] || got["baz.go"] {
+		t.Errorf("bugFixCommits with followRenames didn't canonicalize baz.go to qux.go: %v", got)
+	}
+}
+
+// TestGogitSourceBugFixHunks checks that bugFixHunks turns a bug-fix
+// commit's unified diff into hunks addressed by line number in the file as
+// of that commit (here also HEAD), via go-git's diff encoding and scanHunks.
+func TestGogitSourceBugFixHunks(t *testing.T) {
+	dir := testRepo(t)
+
+	writeFile(t, dir, "foo.go", "1\n2\n3\n4\n5\n")
+	commitAll(t, dir, "add foo")
+
+	writeFile(t, dir, "foo.go", "1\nNEWLINE\n2\n3\n4\n5\n")
+	commitAll(t, dir, "fix #1: insert a line")
+
+	s := newGogitSource(dir)
+	commits, err := s.bugFixHunks(DefaultCommitPattern, false, revisionRange{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commits) != 1 {
+		t.Fatalf("got %d commits, expected 1", len(commits))
+	}
+	want := []hunk{{file: "foo.go", startLine: 2, endLine: 2}}
+	if !reflect.DeepEqual(commits[0].hunks, want) {
+		t.Errorf("got hunks %+v, expected %+v", commits[0].hunks, want)
+	}
+}
+
+// TestGogitSourceReadFile checks that readFile reads through the commit
+// tree -- not the filesystem -- so that it also works against a bare
+// repository, which has no working directory to read from.
+func TestGogitSourceReadFile(t *testing.T) {
+	dir := testRepo(t)
+	writeFile(t, dir, ".gitattributes", "*.min.js linguist-generated\n")
+	commitAll(t, dir, "fix #1: add .gitattributes")
+
+	bareDir := t.TempDir()
+	runGit(t, dir, "clone", "-q", "--bare", dir, bareDir)
+
+	s := newGogitSource(bareDir)
+	data, err := s.readFile(revisionRange{}, ".gitattributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "*.min.js linguist-generated\n" {
+		t.Errorf("got %q, expected %q", data, "*.min.js linguist-generated\n")
+	}
+
+	data, err = s.readFile(revisionRange{}, ".bugspotsignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != nil {
+		t.Errorf("got %q, expected nil for a missing file", data)
+	}
+}
+
+// TestGogitSourceRevisionRange exercises commitsInRange/firstCommitTime/
+// lastCommitTime over a "<from>..<to>" range, which is computed through the
+// commit-graph index (see nodeIndex) rather than the object store directly.
+func TestGogitSourceRevisionRange(t *testing.T) {
+	dir := testRepo(t)
+
+	writeFile(t, dir, "a.go", "package a\n")
+	commitAll(t, dir, "fix #1: add a")
+	runGit(t, dir, "tag", "v1")
+
+	writeFile(t, dir, "b.go", "package b\n")
+	commitAll(t, dir, "fix #2: add b")
+
+	writeFile(t, dir, "c.go", "package c\n")
+	commitAll(t, dir, "fix #3: add c")
+
+	s := newGogitSource(dir)
+	rev := revisionRange{from: "v1"}
+
+	commits, err := s.bugFixCommits(DefaultCommitPattern, false, rev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, c := range commits {
+		files = append(files, c.files...)
+	}
+	sort.Strings(files)
+	if len(files) != 2 || files[0] != "b.go" || files[1] != "c.go" {
+		t.Errorf("got files %v, expected [b.go c.go]", files)
+	}
+
+	tfirst, err := s.firstCommitTime(rev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlast, err := s.lastCommitTime(rev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tfirst > tlast {
+		t.Errorf("firstCommitTime %d is after lastCommitTime %d", tfirst, tlast)
+	}
+}
+
+// TestGogitSourceCommitGraph checks that reading history through the
+// commit-graph file (see commitgraph.go) turns up the same results as
+// falling back to the object store, both when the file is present and when
+// it isn't.
+func TestGogitSourceCommitGraph(t *testing.T) {
+	dir := testRepo(t)
+
+	writeFile(t, dir, "a.go", "package a\n")
+	commitAll(t, dir, "fix #1: add a")
+	writeFile(t, dir, "b.go", "package b\n")
+	commitAll(t, dir, "fix #2: add b")
+
+	withoutGraph := newGogitSource(dir)
+	withoutGraph.useCommitGraph = false
+	wantCommits, err := withoutGraph.bugFixCommits(DefaultCommitPattern, false, revisionRange{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, dir, "commit-graph", "write", "--reachable")
+	if _, err := os.Stat(filepath.Join(dir, ".git", commitGraphPath)); err != nil {
+		t.Fatalf("commit-graph file wasn't written: %v", err)
+	}
+
+	withGraph := newGogitSource(dir)
+	gotCommits, err := withGraph.bugFixCommits(DefaultCommitPattern, false, revisionRange{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gotCommits) != len(wantCommits) {
+		t.Fatalf("got %d commits with commit-graph, expected %d", len(gotCommits), len(wantCommits))
+	}
+	for i := range gotCommits {
+		if !gotCommits[i].t.Equal(wantCommits[i].t) {
+			t.Errorf("commit %d: got time %v, expected %v", i, gotCommits[i].t, wantCommits[i].t)
+		}
+	}
+}
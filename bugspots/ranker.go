@@ -0,0 +1,90 @@
+package bugspots
+
+import (
+	"math"
+	"time"
+)
+
+// RankInput carries everything a Ranker needs to weigh a single bug-fix
+// commit's contribution to a file's score.
+type RankInput struct {
+	// Time is when the commit was made.
+	Time time.Time
+	// First and Last delimit the range of commits being considered; Time
+	// always falls between them.
+	First, Last time.Time
+	// LinesChanged is the number of lines the commit added or removed in
+	// the file, or 0 if that information isn't available. LineHotspots
+	// instead passes the number of new-file lines the hunk spans.
+	LinesChanged int
+}
+
+// Ranker weighs a bug-fix commit's contribution to a file's hotspot score.
+type Ranker interface {
+	Score(in RankInput) float64
+}
+
+func (in RankInput) normalizedTime() float64 {
+	return normalizeTimestamp(in.Time.Unix(), in.First.Unix(), in.Last.Unix())
+}
+
+// SigmoidRanker is the original gobugspots ranking function: a logistic
+// curve that weighs recent commits much more heavily than old ones. It is
+// the default Ranker.
+type SigmoidRanker struct{}
+
+// Score implements Ranker.
+func (SigmoidRanker) Score(in RankInput) float64 {
+	return scoreFunc(in.normalizedTime())
+}
+
+// LinearRanker weighs a commit in direct proportion to how recent it is
+// within the considered range.
+type LinearRanker struct{}
+
+// Score implements Ranker.
+func (LinearRanker) Score(in RankInput) float64 {
+	return in.normalizedTime()
+}
+
+// ExponentialDecayRanker weighs a commit by how many half-lives old it is,
+// measured back from the most recent commit in the considered range.
+type ExponentialDecayRanker struct {
+	halfLifeDays float64
+}
+
+// NewExponentialDecayRanker returns an ExponentialDecayRanker with the
+// given half-life, in days.
+func NewExponentialDecayRanker(halfLifeDays float64) *ExponentialDecayRanker {
+	return &ExponentialDecayRanker{halfLifeDays}
+}
+
+// Score implements Ranker.
+func (r *ExponentialDecayRanker) Score(in RankInput) float64 {
+	if r.halfLifeDays <= 0 {
+		return 0
+	}
+	ageDays := in.Last.Sub(in.Time).Hours() / 24
+	return math.Pow(0.5, ageDays/r.halfLifeDays)
+}
+
+// ChurnWeightedRanker multiplies another Ranker's score by the number of
+// lines the commit changed in the file, so that large changes count for
+// more than one-line fixes.
+type ChurnWeightedRanker struct {
+	ranker Ranker
+}
+
+// NewChurnWeightedRanker returns a ChurnWeightedRanker that multiplies
+// ranker's score by lines changed. If ranker is nil, SigmoidRanker is used.
+func NewChurnWeightedRanker(ranker Ranker) *ChurnWeightedRanker {
+	if ranker == nil {
+		ranker = SigmoidRanker{}
+	}
+	return &ChurnWeightedRanker{ranker}
+}
+
+// Score implements Ranker.
+func (r *ChurnWeightedRanker) Score(in RankInput) float64 {
+	return r.ranker.Score(in) * float64(in.LinesChanged)
+}
@@ -0,0 +1,50 @@
+package bugspots
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinearRankerScore(t *testing.T) {
+	first := time.Unix(50, 0)
+	last := time.Unix(100, 0)
+	var tests = []struct {
+		in  time.Time
+		out float64
+	}{
+		{time.Unix(50, 0), 0},
+		{time.Unix(75, 0), 0.5},
+		{time.Unix(100, 0), 1},
+	}
+
+	for _, tt := range tests {
+		in := RankInput{Time: tt.in, First: first, Last: last}
+		actual := LinearRanker{}.Score(in)
+		if actual != tt.out {
+			t.Errorf("got %v, expected %v", actual, tt.out)
+		}
+	}
+}
+
+func TestExponentialDecayRankerScore(t *testing.T) {
+	last := time.Unix(100, 0)
+	r := NewExponentialDecayRanker(10)
+
+	in := RankInput{Time: last, First: time.Unix(0, 0), Last: last}
+	if actual := r.Score(in); actual != 1 {
+		t.Errorf("got %v, expected %v", actual, 1.0)
+	}
+
+	in = RankInput{Time: time.Unix(100-10*86400, 0), First: time.Unix(0, 0), Last: last}
+	if actual := r.Score(in); actual < 0.49 || actual > 0.51 {
+		t.Errorf("got %v, expected ~0.5", actual)
+	}
+}
+
+func TestChurnWeightedRankerScore(t *testing.T) {
+	r := NewChurnWeightedRanker(LinearRanker{})
+	in := RankInput{Time: time.Unix(75, 0), First: time.Unix(50, 0), Last: time.Unix(100, 0), LinesChanged: 4}
+	if actual := r.Score(in); actual != 2 {
+		t.Errorf("got %v, expected %v", actual, 2.0)
+	}
+}
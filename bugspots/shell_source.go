@@ -0,0 +1,311 @@
+package bugspots
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type commandOutputter func(string, ...string) (string, error)
+
+func newCommandOutputter(dir string) commandOutputter {
+	return func(name string, args ...string) (out string, err error) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+
+		outb, err := cmd.Output()
+		if err != nil {
+			return
+		}
+
+		out = strings.TrimSpace(string(outb[:]))
+		return
+	}
+}
+
+// shellSource is a historySource that shells out to the git binary found on
+// PATH.
+type shellSource struct {
+	commandOutput commandOutputter
+}
+
+// newShellSource returns a historySource that runs git as a subprocess
+// rooted at path.
+func newShellSource(path string) *shellSource {
+	return &shellSource{newCommandOutputter(path)}
+}
+
+func parseLsFiles(raw string) []string {
+	if raw == "" {
+		return []string{}
+	}
+	return strings.Split(raw, "\n")
+}
+
+// revArgs returns the git log/rev-list arguments that restrict a command to
+// rev, i.e. a "<from>..<to>" range, a bare "<to>", and/or a "--since".
+func revArgs(rev revisionRange) []string {
+	args := []string{}
+	if rev.since != nil {
+		args = append(args, "--since="+rev.since.Format(time.RFC3339))
+	}
+	switch {
+	case rev.from != "" && rev.to != "":
+		args = append(args, rev.from+".."+rev.to)
+	case rev.from != "":
+		args = append(args, rev.from+"..HEAD")
+	case rev.to != "":
+		args = append(args, rev.to)
+	default:
+		args = append(args, "HEAD")
+	}
+	return args
+}
+
+// pathspecArgs returns the trailing "-- <pathspec>..." arguments that
+// restrict a command to pathspec, or nil if pathspec is empty.
+func pathspecArgs(pathspec []string) []string {
+	if len(pathspec) == 0 {
+		return nil
+	}
+	return append([]string{"--"}, pathspec...)
+}
+
+// headFiles returns the files at rev.to (or HEAD if unset), restricted to
+// pathspec if non-empty.
+func (s *shellSource) headFiles(rev revisionRange, pathspec []string) (headFiles []string, err error) {
+	var out string
+	if rev.to != "" {
+		args := append([]string{"ls-tree", "-r", "--name-only", rev.to}, pathspecArgs(pathspec)...)
+		out, err = s.commandOutput("git", args...)
+	} else {
+		args := append([]string{"ls-files"}, pathspecArgs(pathspec)...)
+		out, err = s.commandOutput("git", args...)
+	}
+	if err != nil {
+		return
+	}
+	headFiles = parseLsFiles(out)
+	return
+}
+
+// readFile returns the contents of path as of rev.to (or HEAD if unset), or
+// nil if no such file exists in that tree. A non-zero git exit status is
+// treated as "missing" rather than an error, since `git show <rev>:<path>`
+// fails that way for a path that doesn't exist in the tree.
+func (s *shellSource) readFile(rev revisionRange, path string) ([]byte, error) {
+	ref := rev.to
+	if ref == "" {
+		ref = "HEAD"
+	}
+	out, err := s.commandOutput("git", "show", ref+":"+path)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// assumes `git log --format=format:%ct --numstat'
+func parseLog(raw string) ([]commit, error) {
+	if raw == "" {
+		return []commit{}, nil
+	}
+	commits := []commit{}
+	for _, commitRaw := range strings.Split(raw, "\n\n") {
+		lines := strings.Split(commitRaw, "\n")
+		timestamp, err := strconv.Atoi(lines[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp '%v'", lines[0])
+		}
+		t := time.Unix(int64(timestamp), 0)
+
+		files := []string{}
+		churn := map[string]int{}
+		for _, line := range lines[1:] {
+			added, deleted, file, ok := parseNumstatLine(line)
+			if !ok {
+				continue
+			}
+			files = append(files, file)
+			churn[file] = added + deleted
+		}
+		commits = append(commits, commit{t, files, churn})
+	}
+	return commits, nil
+}
+
+func parseNumstatLine(line string) (added, deleted int, file string, ok bool) {
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) != 3 {
+		return 0, 0, "", false
+	}
+	added, addErr := strconv.Atoi(parts[0])
+	deleted, delErr := strconv.Atoi(parts[1])
+	if addErr != nil || delErr != nil {
+		// Binary files report "-" instead of a line count.
+		return 0, 0, parts[2], true
+	}
+	return added, deleted, parts[2], true
+}
+
+// bugFixCommits returns the bug-fix commits.
+func (s *shellSource) bugFixCommits(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]commit, error) {
+	// --diff-filter ignores commits with no files attached
+	args := []string{"log", "--diff-filter=ACDMRTUXB",
+		"-E", "-i", "--grep", pattern, "--format=format:%ct", "--numstat"}
+	args = append(args, revArgs(rev)...)
+	args = append(args, pathspecArgs(pathspec)...)
+	out, err := s.commandOutput("git", args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseLog(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if followRenames {
+		renames, err := s.renameMap()
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range commits {
+			churn := map[string]int{}
+			for j, file := range c.files {
+				canonical := canonicalizePath(renames, file)
+				commits[i].files[j] = canonical
+				churn[canonical] += c.churn[file]
+			}
+			commits[i].churn = churn
+		}
+	}
+
+	return commits, nil
+}
+
+// assumes `git log --format=format:%ct -U0 -p'
+func parseLogHunks(raw string) ([]hunkCommit, error) {
+	if raw == "" {
+		return []hunkCommit{}, nil
+	}
+	commits := []hunkCommit{}
+	for _, commitRaw := range strings.Split(raw, "\n\n") {
+		lines := strings.Split(commitRaw, "\n")
+		timestamp, err := strconv.Atoi(lines[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp '%v'", lines[0])
+		}
+		t := time.Unix(int64(timestamp), 0)
+		commits = append(commits, hunkCommit{t, scanHunks(lines[1:])})
+	}
+	return commits, nil
+}
+
+// bugFixHunks returns the same commits as bugFixCommits, broken down into
+// the hunks they touched instead of whole files.
+func (s *shellSource) bugFixHunks(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]hunkCommit, error) {
+	args := []string{"log", "--diff-filter=ACDMRTUXB",
+		"-E", "-i", "--grep", pattern, "--format=format:%ct", "-U0", "-p"}
+	args = append(args, revArgs(rev)...)
+	args = append(args, pathspecArgs(pathspec)...)
+	out, err := s.commandOutput("git", args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseLogHunks(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if followRenames {
+		renames, err := s.renameMap()
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range commits {
+			for j, h := range c.hunks {
+				commits[i].hunks[j].file = canonicalizePath(renames, h.file)
+			}
+		}
+	}
+
+	return commits, nil
+}
+
+var renameStatusPattern = regexp.MustCompile(`^R\d*\t([^\t]+)\t([^\t]+)$`)
+
+// renameMap returns a map of every historical path to the path it was
+// renamed to, built by walking the whole history oldest-first so that a
+// chain of renames can be followed forward to a file's current-HEAD name.
+func (s *shellSource) renameMap() (map[string]string, error) {
+	out, err := s.commandOutput("git", "log", "--reverse", "--diff-filter=R",
+		"--find-renames", "--name-status", "--format=format:")
+	if err != nil {
+		return nil, err
+	}
+
+	renames := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		m := renameStatusPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		renames[m[1]] = m[2]
+	}
+	return renames, nil
+}
+
+func parseRevList(raw string) (int64, error) {
+	lines := strings.Split(raw, "\n")
+	if len(lines) != 2 {
+		return 0, errors.New("no commits")
+	}
+	t, err := strconv.Atoi(lines[1])
+	return int64(t), err
+}
+
+// parseFirstRevListEntry returns the timestamp of the first "commit
+// <sha>"/"<ct>" line pair in the output of `git rev-list --format=%ct`, i.e.
+// the commit git listed first. Unlike parseRevList, it doesn't require the
+// output to consist of a single commit, since --max-count=1 combined with
+// --reverse would pick the newest commit instead of the oldest: git applies
+// --max-count during the newest-first traversal, before --reverse reorders
+// the (already-truncated) result.
+func parseFirstRevListEntry(raw string) (int64, error) {
+	lines := strings.Split(raw, "\n")
+	if len(lines) < 2 {
+		return 0, errors.New("no commits")
+	}
+	t, err := strconv.Atoi(lines[1])
+	return int64(t), err
+}
+
+// firstCommitTime returns the timestamp of the first (oldest) commit in rev.
+func (s *shellSource) firstCommitTime(rev revisionRange, pathspec []string) (t int64, err error) {
+	args := append([]string{"rev-list", "--reverse", "--format=%ct"}, revArgs(rev)...)
+	args = append(args, pathspecArgs(pathspec)...)
+	out, err := s.commandOutput("git", args...)
+	if err != nil {
+		return
+	}
+	return parseFirstRevListEntry(out)
+}
+
+// lastCommitTime returns the timestamp of the last (newest) commit in rev.
+func (s *shellSource) lastCommitTime(rev revisionRange, pathspec []string) (t int64, err error) {
+	args := append([]string{"rev-list", "--max-count=1", "--format=%ct"}, revArgs(rev)...)
+	args = append(args, pathspecArgs(pathspec)...)
+	out, err := s.commandOutput("git", args...)
+	if err != nil {
+		return
+	}
+	return parseRevList(out)
+}
@@ -0,0 +1,131 @@
+package bugspots
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mockCommandOutputter(out string) commandOutputter {
+	return func(_ string, _ ...string) (string, error) {
+		return out, nil
+	}
+}
+
+func TestShellSourceHeadFiles(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out []string
+	}{
+		{"", []string{}},
+		{"foo", []string{"foo"}},
+		{"foo\nbar", []string{"foo", "bar"}},
+	}
+
+	source := &shellSource{}
+	for _, tt := range tests {
+		source.commandOutput = mockCommandOutputter(tt.in)
+		actual, _ := source.headFiles(revisionRange{}, nil)
+		if !reflect.DeepEqual(actual, tt.out) {
+			t.Errorf("got %#v, expected %#v", actual, tt.out)
+		}
+	}
+}
+
+func TestShellSourceBugFixCommits(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out []commit
+	}{
+		{"", []commit{}},
+		{"1\n3\t1\tfoo\n0\t2\tbar\n\n2\n-\t-\tbaz", []commit{
+			commit{t: time.Unix(1, 0), files: []string{"foo", "bar"}, churn: map[string]int{"foo": 4, "bar": 2}},
+			commit{t: time.Unix(2, 0), files: []string{"baz"}, churn: map[string]int{"baz": 0}},
+		}},
+	}
+
+	source := &shellSource{}
+	for _, tt := range tests {
+		source.commandOutput = mockCommandOutputter(tt.in)
+		actual, _ := source.bugFixCommits(DefaultCommitPattern, false, revisionRange{}, nil)
+		if !reflect.DeepEqual(actual, tt.out) {
+			t.Errorf("got %#v, expected %#v", actual, tt.out)
+		}
+	}
+}
+
+func TestShellSourceBugFixHunks(t *testing.T) {
+	raw := "1\n" +
+		"diff --git a/foo.go b/foo.go\n" +
+		"--- a/foo.go\n" +
+		"+++ b/foo.go\n" +
+		"@@ -10 +10,2 @@ func f() {\n" +
+		"+added\n" +
+		"+added2\n"
+
+	source := &shellSource{commandOutput: mockCommandOutputter(raw)}
+	actual, err := source.bugFixHunks(DefaultCommitPattern, false, revisionRange{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []hunkCommit{
+		{t: time.Unix(1, 0), hunks: []hunk{{file: "foo.go", startLine: 10, endLine: 11}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestShellSourceFirstCommitTime(t *testing.T) {
+	source := &shellSource{commandOutput: mockCommandOutputter("hash\n1")}
+	actual, err := source.firstCommitTime(revisionRange{}, nil)
+	if actual != 1 {
+		t.Errorf("got (%v, %v), expected (%v, <nil>)", actual, err, 1)
+	}
+}
+
+// TestShellSourceFirstCommitTimeMultipleCommits guards against regressing to
+// `--reverse --max-count=1`, which git applies in the wrong order: it
+// truncates to the single newest commit before reversing, so it ends up
+// returning the same commit as lastCommitTime instead of the oldest one.
+func TestShellSourceFirstCommitTimeMultipleCommits(t *testing.T) {
+	source := &shellSource{commandOutput: mockCommandOutputter("hashold\n1\n\nhashnew\n2")}
+	actual, err := source.firstCommitTime(revisionRange{}, nil)
+	if actual != 1 {
+		t.Errorf("got (%v, %v), expected (%v, <nil>)", actual, err, 1)
+	}
+}
+
+func TestShellSourceLastCommitTime(t *testing.T) {
+	source := &shellSource{commandOutput: mockCommandOutputter("hash\n2")}
+	actual, err := source.lastCommitTime(revisionRange{}, nil)
+	if actual != 2 {
+		t.Errorf("got (%v, %v), expected (%v, <nil>)", actual, err, 2)
+	}
+}
+
+func TestShellSourceReadFile(t *testing.T) {
+	source := &shellSource{commandOutput: mockCommandOutputter("linguist-generated\n")}
+	data, err := source.readFile(revisionRange{}, ".gitattributes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "linguist-generated\n" {
+		t.Errorf("got %q, expected %q", data, "linguist-generated\n")
+	}
+}
+
+func TestShellSourceReadFileMissing(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	source := &shellSource{commandOutput: func(string, ...string) (string, error) {
+		return "", exitErr
+	}}
+	data, err := source.readFile(revisionRange{}, "missing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data != nil {
+		t.Errorf("got %q, expected nil", data)
+	}
+}
@@ -0,0 +1,161 @@
+package bugspots
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commit represents a single commit touching one or more files.
+type commit struct {
+	t     time.Time
+	files []string
+	// churn maps a touched file to the number of lines it added and
+	// removed in this commit. It may be nil if that information wasn't
+	// collected.
+	churn map[string]int
+}
+
+// revisionRange restricts history traversal to a subset of commits, as set
+// by Bugspots.SetRevisionRange and Bugspots.SetSince.
+type revisionRange struct {
+	// From and To are revision expressions (a branch, tag, SHA, or similar)
+	// understood by the backend. An empty From walks back to the root
+	// commit; an empty To defaults to HEAD.
+	from, to string
+	// since, if set, additionally drops commits older than the given time.
+	since *time.Time
+}
+
+// isZero reports whether rev selects the whole history, i.e. no range was
+// configured.
+func (rev revisionRange) isZero() bool {
+	return rev.from == "" && rev.to == "" && rev.since == nil
+}
+
+// matchesPathspec reports whether path falls under one of the given
+// pathspecs, matching a pathspec literally or as an ancestor directory of
+// path -- the same default, non-glob pathspec semantics git uses for plain
+// paths. An empty pathspec matches everything.
+func matchesPathspec(pathspec []string, path string) bool {
+	if len(pathspec) == 0 {
+		return true
+	}
+	for _, p := range pathspec {
+		p = strings.TrimSuffix(p, "/")
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hunk is a contiguous range of lines touched by a commit in a single file,
+// numbered as in that file right after the commit.
+type hunk struct {
+	file      string
+	startLine int
+	endLine   int
+}
+
+// hunkCommit is a bug-fix commit broken down into the hunks it touched,
+// rather than whole files.
+type hunkCommit struct {
+	t     time.Time
+	hunks []hunk
+}
+
+// historySource abstracts the operations gobugspots needs from a repository's
+// history, so that the same Bugspots logic can run against different
+// backends (shelling out to git, go-git, ...).
+type historySource interface {
+	// headFiles returns the files at rev.to (or HEAD if unset), restricted
+	// to pathspec if non-empty.
+	headFiles(rev revisionRange, pathspec []string) ([]string, error)
+
+	// bugFixCommits returns the commits whose message matches pattern,
+	// restricted to rev and pathspec. When followRenames is set, the
+	// returned commits' file paths are canonicalized to their current-HEAD
+	// name, so that a file renamed after the fact still matches up with
+	// headFiles.
+	bugFixCommits(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]commit, error)
+
+	// bugFixHunks returns the same commits as bugFixCommits, broken down
+	// into the hunks they touched instead of whole files.
+	bugFixHunks(pattern string, followRenames bool, rev revisionRange, pathspec []string) ([]hunkCommit, error)
+
+	// firstCommitTime returns the timestamp of the first commit in rev.
+	firstCommitTime(rev revisionRange, pathspec []string) (int64, error)
+
+	// lastCommitTime returns the timestamp of the last commit in rev.
+	lastCommitTime(rev revisionRange, pathspec []string) (int64, error)
+
+	// readFile returns the contents of path as of rev.to (or HEAD if
+	// unset), or nil if no such file exists in that tree. This is used to
+	// read .gitattributes/.bugspotsignore from the repository itself
+	// rather than the filesystem, so filtering works against bare
+	// repositories and other non-filesystem storers too.
+	readFile(rev revisionRange, path string) ([]byte, error)
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// scanHunks extracts the hunks of a unified diff (as produced by `git
+// diff -U0` or go-git's diff.UnifiedEncoder with zero context lines) from
+// its lines, attributing each hunk to the file named by the last "+++ b/..."
+// line seen. Lines belonging to a deleted file ("+++ /dev/null") are
+// ignored, since there is no current-HEAD file to attribute them to.
+func scanHunks(lines []string) []hunk {
+	hunks := []hunk{}
+	curFile := ""
+	for _, line := range lines {
+		if strings.HasPrefix(line, "+++ ") {
+			f := strings.TrimPrefix(line, "+++ ")
+			f = strings.TrimPrefix(f, "b/")
+			if f == "/dev/null" {
+				curFile = ""
+			} else {
+				curFile = f
+			}
+			continue
+		}
+		m := hunkHeaderPattern.FindStringSubmatch(line)
+		if m == nil || curFile == "" {
+			continue
+		}
+		start, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		length := 1
+		if m[2] != "" {
+			length, _ = strconv.Atoi(m[2])
+		}
+		end := start + length - 1
+		if length == 0 {
+			// A pure deletion has no lines in the new file; the header's
+			// start is then the line just before the deleted ones, and
+			// the hunk is anchored there.
+			end = start
+		}
+		hunks = append(hunks, hunk{curFile, start, end})
+	}
+	return hunks
+}
+
+// canonicalizePath follows the chain of renames in renames (oldPath ->
+// newPath) starting at path, returning the name path eventually ends up
+// under. It is used to map a file's historical names onto its current-HEAD
+// name.
+func canonicalizePath(renames map[string]string, path string) string {
+	seen := map[string]bool{}
+	for {
+		next, ok := renames[path]
+		if !ok || seen[path] {
+			return path
+		}
+		seen[path] = true
+		path = next
+	}
+}
@@ -0,0 +1,25 @@
+package bugspots
+
+import "testing"
+
+func TestMatchesPathspec(t *testing.T) {
+	var tests = []struct {
+		pathspec []string
+		path     string
+		out      bool
+	}{
+		{nil, "foo.go", true},
+		{[]string{"internal"}, "internal/foo.go", true},
+		{[]string{"internal"}, "internal", true},
+		{[]string{"internal/"}, "internal/foo.go", true},
+		{[]string{"internal"}, "internal2/foo.go", false},
+		{[]string{"cmd"}, "internal/foo.go", false},
+	}
+
+	for _, tt := range tests {
+		actual := matchesPathspec(tt.pathspec, tt.path)
+		if actual != tt.out {
+			t.Errorf("matchesPathspec(%v, %q) = %v, expected %v", tt.pathspec, tt.path, actual, tt.out)
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sprt/gobugspots/bugspots"
 )
@@ -14,11 +17,20 @@ const (
 )
 
 var (
-	minCount   int
-	maxCount   int
-	percentile float64
-	pattern    string
-	path       = defaultPath
+	minCount      int
+	maxCount      int
+	percentile    float64
+	pattern       string
+	shellGit      bool
+	follow        bool
+	include       string
+	exclude       string
+	format        string
+	rev           string
+	since         string
+	pathspec      string
+	noCommitGraph bool
+	path          = defaultPath
 )
 
 func init() {
@@ -26,6 +38,70 @@ func init() {
 	flag.IntVar(&maxCount, "max-count", bugspots.DefaultMaxCount, "maxium number of hotspots to show")
 	flag.Float64Var(&percentile, "percentile", bugspots.DefaultPercentile, "upper percentile of hotspots to show")
 	flag.StringVar(&pattern, "pattern", bugspots.DefaultCommitPattern, "regular expression used to match bug-fixing commits")
+	flag.BoolVar(&shellGit, "shell-git", false, "shell out to the git binary instead of using the built-in go-git backend")
+	flag.BoolVar(&follow, "follow", false, "follow file renames so a file's bug-fix history survives moves")
+	flag.StringVar(&include, "include", "", "comma-separated glob patterns (\"**\" allowed); only matching files are analyzed")
+	flag.StringVar(&exclude, "exclude", "", "comma-separated glob patterns (\"**\" allowed) of files to skip")
+	flag.StringVar(&format, "format", "text", "output format: text, json, csv, or sarif")
+	flag.StringVar(&rev, "rev", "", "restrict analysis to a revision range, e.g. \"v1.0..HEAD\" or a bare \"<sha>\"")
+	flag.StringVar(&since, "since", "", "restrict analysis to commits newer than this, e.g. \"90d\"")
+	flag.StringVar(&pathspec, "pathspec", "", "comma-separated paths; only commits touching these subtrees are analyzed")
+	flag.BoolVar(&noCommitGraph, "no-commit-graph", false, "don't accelerate history traversal with the repository's commit-graph file, even if present")
+}
+
+func splitGlobs(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// parseRevRange splits a "<from>..<to>" revision range into its two halves.
+// A range with no "..", such as a bare sha or branch, is returned as to with
+// an empty from. Git's "<from>...<to>" symmetric-difference syntax is
+// accepted too, but treated the same as "<from>..<to>" rather than computing
+// the true symmetric difference.
+func parseRevRange(s string) (from, to string) {
+	if i := strings.Index(s, "..."); i >= 0 {
+		return s[:i], s[i+3:]
+	}
+	if i := strings.Index(s, ".."); i >= 0 {
+		return s[:i], s[i+2:]
+	}
+	return "", s
+}
+
+// parseSince converts a duration shortcut like "90d", "2w", or "6m" into the
+// time before which commits should be dropped. The unit suffixes are days,
+// weeks, months (30 days), and years (365 days); anything else is parsed as
+// a Go duration (e.g. "36h").
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	unit := s[len(s)-1]
+	var days float64
+	switch unit {
+	case 'd':
+		days = 1
+	case 'w':
+		days = 7
+	case 'm':
+		days = 30
+	case 'y':
+		days = 365
+	default:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid -since %q: %v", s, err)
+		}
+		return time.Now().Add(-d), nil
+	}
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -since %q: %v", s, err)
+	}
+	return time.Now().Add(-time.Duration(n * days * 24 * float64(time.Hour))), nil
 }
 
 func usage() {
@@ -42,9 +118,30 @@ func main() {
 		path = flag.Args()[0]
 	}
 
-	repo := bugspots.NewRepoByPath(path)
+	var repo *bugspots.Repo
+	if shellGit {
+		repo = bugspots.NewShellRepoByPath(path)
+	} else {
+		repo = bugspots.NewRepoByPath(path)
+	}
+	repo.SetUseCommitGraph(!noCommitGraph)
 	b := bugspots.NewBugspots(repo)
 	b.SetPattern(pattern)
+	b.SetFollowRenames(follow)
+	b.SetIncludeGlobs(splitGlobs(include))
+	b.SetExcludeGlobs(splitGlobs(exclude))
+	b.SetPathspec(splitGlobs(pathspec))
+	if rev != "" {
+		from, to := parseRevRange(rev)
+		b.SetRevisionRange(from, to)
+	}
+	if since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		b.SetSince(t)
+	}
 
 	hotspots, err := b.Hotspots()
 	if err != nil {
@@ -56,8 +153,8 @@ func main() {
 	s.SetMaxCount(maxCount)
 	hotspots = s.Slice(hotspots)
 
-	for _, h := range hotspots {
-		fmt.Printf("%.4f %s\n", h.Score, h.File)
+	if err := writeHotspots(os.Stdout, format, hotspots); err != nil {
+		log.Fatalln(err)
 	}
 
 	if len(hotspots) == 0 {
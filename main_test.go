@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestParseRevRange(t *testing.T) {
+	var tests = []struct {
+		in       string
+		from, to string
+	}{
+		{"HEAD", "", "HEAD"},
+		{"v1.0..HEAD", "v1.0", "HEAD"},
+		{"origin/main~50..origin/main", "origin/main~50", "origin/main"},
+		{"main...feature", "main", "feature"},
+	}
+
+	for _, tt := range tests {
+		from, to := parseRevRange(tt.in)
+		if from != tt.from || to != tt.to {
+			t.Errorf("parseRevRange(%q) = (%q, %q), expected (%q, %q)", tt.in, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	var tests = []struct {
+		in      string
+		wantErr bool
+	}{
+		{"", false},
+		{"90d", false},
+		{"2w", false},
+		{"6m", false},
+		{"1y", false},
+		{"36h", false},
+		{"bogus", true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseSince(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSince(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sprt/gobugspots/bugspots"
+)
+
+// writeText writes hotspots in the original "%.4f %s" format, one per line.
+func writeText(w io.Writer, hotspots []bugspots.Hotspot) error {
+	for _, h := range hotspots {
+		if _, err := fmt.Fprintf(w, "%.4f %s\n", h.Score, h.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes hotspots as a JSON array of {"file", "score"} objects.
+func writeJSON(w io.Writer, hotspots []bugspots.Hotspot) error {
+	type jsonHotspot struct {
+		File  string  `json:"file"`
+		Score float64 `json:"score"`
+	}
+	out := make([]jsonHotspot, len(hotspots))
+	for i, h := range hotspots {
+		out[i] = jsonHotspot{h.File, h.Score}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// writeCSV writes hotspots as CSV with a "file,score" header.
+func writeCSV(w io.Writer, hotspots []bugspots.Hotspot) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"file", "score"}); err != nil {
+		return err
+	}
+	for _, h := range hotspots {
+		if err := cw.Write([]string{h.File, strconv.FormatFloat(h.Score, 'f', -1, 64)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// sarifLevel buckets a hotspot's rank among its peers into a SARIF result
+// level: ranks below total/3 are "error", ranks below 2*total/3 "warning",
+// and the rest "note", with both bounds rounded down (so of two hotspots the
+// hotter is "warning"). A lone hotspot is "error".
+func sarifLevel(rank, total int) string {
+	switch {
+	case total <= 1:
+		return "error"
+	case rank < total/3:
+		return "error"
+	case rank < 2*total/3:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifLog, sarifRun, sarifTool, sarifRule, sarifResult, sarifLocation,
+// sarifPhysicalLocation, and sarifArtifactLocation model the subset of the
+// SARIF 2.1.0 schema gobugspots emits.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+const sarifRuleID = "gobugspots/hotspot"
+
+// writeSARIF writes hotspots as a SARIF 2.1.0 log with one result per
+// hotspot, so that CI systems such as GitHub code scanning can ingest them
+// directly.
+func writeSARIF(w io.Writer, hotspots []bugspots.Hotspot) error {
+	results := make([]sarifResult, len(hotspots))
+	for i, h := range hotspots {
+		results[i] = sarifResult{
+			RuleID: sarifRuleID,
+			Level:  sarifLevel(i, len(hotspots)),
+			Message: sarifMessage{
+				Text: fmt.Sprintf("%s is a bug-fix hotspot (score %.4f)", h.File, h.Score),
+			},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: h.File},
+				},
+			}},
+		}
+	}
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "gobugspots",
+				InformationURI: "https://github.com/sprt/gobugspots",
+				Rules:          []sarifRule{{ID: sarifRuleID}},
+			}},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// writeHotspots writes hotspots to w in the given format, one of "text",
+// "json", "csv", or "sarif".
+func writeHotspots(w io.Writer, format string, hotspots []bugspots.Hotspot) error {
+	switch format {
+	case "text":
+		return writeText(w, hotspots)
+	case "json":
+		return writeJSON(w, hotspots)
+	case "csv":
+		return writeCSV(w, hotspots)
+	case "sarif":
+		return writeSARIF(w, hotspots)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
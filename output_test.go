@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sprt/gobugspots/bugspots"
+)
+
+var testHotspots = []bugspots.Hotspot{
+	{File: "foo.go", Score: 0.9},
+	{File: "bar.go", Score: 0.5},
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeText(&buf, testHotspots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "0.9000 foo.go\n0.5000 bar.go\n"
+	if buf.String() != want {
+		t.Errorf("got %q, expected %q", buf.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, testHotspots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out []struct {
+		File  string  `json:"file"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(out) != 2 || out[0].File != "foo.go" || out[0].Score != 0.9 {
+		t.Errorf("got %+v, expected hotspots matching %+v", out, testHotspots)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, testHotspots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "file,score\nfoo.go,0.9\nbar.go,0.5\n"
+	if buf.String() != want {
+		t.Errorf("got %q, expected %q", buf.String(), want)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, testHotspots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(out.Runs) != 1 || len(out.Runs[0].Results) != 2 {
+		t.Fatalf("got %+v, expected a single run with 2 results", out)
+	}
+	if out.Runs[0].Results[0].Level != sarifLevel(0, len(testHotspots)) {
+		t.Errorf("got level %q for the hottest result, expected %q", out.Runs[0].Results[0].Level, sarifLevel(0, len(testHotspots)))
+	}
+	if !strings.Contains(out.Runs[0].Results[0].Message.Text, "foo.go") {
+		t.Errorf("message %q doesn't mention foo.go", out.Runs[0].Results[0].Message.Text)
+	}
+}
+
+func TestWriteHotspotsUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeHotspots(&buf, "bogus", testHotspots); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestSarifLevel(t *testing.T) {
+	var tests = []struct {
+		rank, total int
+		out         string
+	}{
+		{0, 0, "error"},
+		{0, 1, "error"},
+		{0, 2, "warning"},
+		{1, 2, "note"},
+		{0, 3, "error"},
+		{1, 3, "warning"},
+		{2, 3, "note"},
+		{0, 9, "error"},
+		{2, 9, "error"},
+		{3, 9, "warning"},
+		{5, 9, "warning"},
+		{6, 9, "note"},
+		{8, 9, "note"},
+	}
+
+	for _, tt := range tests {
+		actual := sarifLevel(tt.rank, tt.total)
+		if actual != tt.out {
+			t.Errorf("sarifLevel(%d, %d) = %q, expected %q", tt.rank, tt.total, actual, tt.out)
+		}
+	}
+}